@@ -0,0 +1,36 @@
+package sciuromorpha
+
+// AuthMethod selects how a GitClient authenticates with a remote. Each Backend interprets the
+// concrete type via a type switch, similar to go-git's transport.AuthMethod sum type.
+type AuthMethod interface {
+	isAuthMethod()
+}
+
+// SSHKey authenticates using a private key on disk (PrivateKeyPath + ".pub" for the public half),
+// optionally protected by a passphrase.
+type SSHKey struct {
+	PrivateKeyPath string
+	Passphrase     string
+}
+
+func (SSHKey) isAuthMethod() {}
+
+// SSHAgent authenticates using keys offered by the running ssh-agent.
+type SSHAgent struct{}
+
+func (SSHAgent) isAuthMethod() {}
+
+// HTTPBasic authenticates HTTP(S) remotes with a username and password.
+type HTTPBasic struct {
+	User     string
+	Password string
+}
+
+func (HTTPBasic) isAuthMethod() {}
+
+// HTTPToken authenticates HTTP(S) remotes with a bearer/personal-access token.
+type HTTPToken struct {
+	Token string
+}
+
+func (HTTPToken) isAuthMethod() {}
@@ -0,0 +1,78 @@
+package sciuromorpha
+
+import "context"
+
+// Backend selects which underlying git implementation a GitClient uses.
+type Backend int
+
+const (
+	// BackendLibgit2 uses libgit2 via git2go. It requires cgo and a matching system libgit2, and
+	// is excluded from binaries built with the "nolibgit2" build tag.
+	BackendLibgit2 Backend = iota
+	// BackendGoGit uses the pure-Go github.com/go-git/go-git/v5 implementation. It has no cgo
+	// dependency, making it suitable for static or cross-compiled binaries.
+	BackendGoGit
+)
+
+// Oid identifies a commit, independent of the underlying git implementation.
+type Oid string
+
+// String returns the commit hash this Oid represents.
+func (o Oid) String() string {
+	return string(o)
+}
+
+// Tag is a backend-agnostic view of a tag reference.
+type Tag struct {
+	Name   string
+	Target Oid
+}
+
+// ProgressEvent reports incremental progress for a context-aware fetch. Phase is a short
+// human-readable label (e.g. "transfer", "sideband"); Received/Total/Bytes are populated on a
+// best-effort basis and may be zero when the backend can't report them.
+type ProgressEvent struct {
+	Phase    string
+	Received int
+	Total    int
+	Bytes    int
+}
+
+// Fetcher is an interface describing a remote fetcher
+type Fetcher interface {
+	Fetch(refspecs []string, auth AuthMethod, verifier HostKeyVerifier) error
+	// FetchContext behaves like Fetch, but honors ctx for cancellation and, if progress is
+	// non-nil, reports incremental ProgressEvents as the fetch proceeds.
+	FetchContext(ctx context.Context, refspecs []string, auth AuthMethod, verifier HostKeyVerifier, progress func(ProgressEvent)) error
+	Free()
+}
+
+// Gitter is an interface representing the required operations for this library that a repository
+// must implement, independent of the underlying git implementation
+type Gitter interface {
+	Free()
+	RemotesLookup(string) (Fetcher, error)
+	GetTag(name string) (*Tag, error)
+	ListTags() ([]Tag, error)
+	LookupBranch(name string) (Oid, error)
+	LookupCommit(hash string) (Oid, error)
+	CheckoutTree(oid Oid, ref string) error
+	CommitInfo(oid Oid) (author, message string, err error)
+}
+
+// openGitter opens path with the requested Backend
+func openGitter(path string, backend Backend) (Gitter, error) {
+	if backend == BackendGoGit {
+		return openGoGitGitter(path)
+	}
+	return openLibgit2Gitter(path)
+}
+
+// cloneGitter clones url into destPath with the requested Backend. depth is a shallow-clone
+// depth hint; backends that don't support shallow clones ignore it.
+func cloneGitter(url, destPath string, backend Backend, depth int, auth AuthMethod, verifier HostKeyVerifier) (Gitter, error) {
+	if backend == BackendGoGit {
+		return cloneGoGitGitter(url, destPath, depth, auth, verifier)
+	}
+	return cloneLibgit2Gitter(url, destPath, auth, verifier)
+}
@@ -0,0 +1,107 @@
+package sciuromorpha
+
+import (
+	"errors"
+
+	semver "github.com/Masterminds/semver/v3"
+)
+
+// CheckoutStrategy resolves the commit and symbolic ref that a checkout operation should
+// materialize, leaving the shared fetch/checkout-tree/SetHead/sparse-prune pipeline in GitClient
+// to do the rest.
+type CheckoutStrategy interface {
+	Resolve(repo Gitter) (Oid, string, error)
+}
+
+// defaultRefspecs fetches every branch and tag, for strategies that don't know their target ref
+// up front (e.g. resolving a commit hash or a semver constraint against all tags).
+func defaultRefspecs() []string {
+	return []string{"+refs/heads/*:refs/remotes/origin/*", "refs/tags/*:refs/tags/*"}
+}
+
+// refspecsForStrategy narrows the fetch refspecs to the specific tag or branch a strategy already
+// knows it wants, rather than always pulling every ref. Strategies that can't name their target up
+// front fall back to defaultRefspecs.
+func refspecsForStrategy(strat CheckoutStrategy) []string {
+	switch s := strat.(type) {
+	case tagStrategy:
+		return []string{"refs/tags/" + s.tag + ":refs/tags/" + s.tag}
+	case branchStrategy:
+		return []string{"+refs/heads/" + s.branch + ":refs/remotes/origin/" + s.branch}
+	default:
+		return defaultRefspecs()
+	}
+}
+
+type tagStrategy struct {
+	tag string
+}
+
+func (s tagStrategy) Resolve(repo Gitter) (Oid, string, error) {
+	t, err := repo.GetTag(s.tag)
+	if err != nil {
+		return "", "", err
+	}
+	if t == nil {
+		return "", "", errors.New("Unabled to find specified tag")
+	}
+
+	return t.Target, "refs/tags/" + s.tag, nil
+}
+
+type branchStrategy struct {
+	branch string
+}
+
+func (s branchStrategy) Resolve(repo Gitter) (Oid, string, error) {
+	oid, err := repo.LookupBranch(s.branch)
+	if err != nil {
+		return "", "", err
+	}
+
+	return oid, "refs/heads/" + s.branch, nil
+}
+
+type commitStrategy struct {
+	hash string
+}
+
+func (s commitStrategy) Resolve(repo Gitter) (Oid, string, error) {
+	oid, err := repo.LookupCommit(s.hash)
+	if err != nil {
+		return "", "", err
+	}
+
+	return oid, oid.String(), nil
+}
+
+type semVerStrategy struct {
+	constraint string
+}
+
+func (s semVerStrategy) Resolve(repo Gitter) (Oid, string, error) {
+	constraint, err := semver.NewConstraint(s.constraint)
+	if err != nil {
+		return "", "", err
+	}
+
+	tags, err := repo.ListTags()
+	if err != nil {
+		return "", "", err
+	}
+
+	var bestName string
+	var best *semver.Version
+	for _, t := range tags {
+		v, err := semver.NewVersion(t.Name)
+		if err == nil && constraint.Check(v) && (best == nil || v.GreaterThan(best)) {
+			best = v
+			bestName = t.Name
+		}
+	}
+	if best == nil {
+		return "", "", errors.New("Unabled to find a tag matching the specified constraint")
+	}
+
+	return tagStrategy{tag: bestName}.Resolve(repo)
+}
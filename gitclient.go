@@ -1,230 +1,226 @@
 package sciuromorpha
 
 import (
+	"context"
 	"errors"
 	"io/ioutil"
 	"os"
 	dirpath "path"
+	"path/filepath"
 	"strings"
-
-	git "gopkg.in/libgit2/git2go.v24"
 )
 
-var checkoutOpts = &git.CheckoutOpts{
-	Strategy: git.CheckoutSafe | git.CheckoutRecreateMissing | git.CheckoutAllowConflicts | git.CheckoutUseTheirs,
+// GitClient manages a reference to a git repository on disk
+type GitClient struct {
+	repository      Gitter
+	repoPath        string
+	auth            AuthMethod
+	hostKeyVerifier HostKeyVerifier
 }
 
-// Fetcher is an interface describing a remote fetcher
-type Fetcher interface {
-	Fetch([]string, *git.FetchOptions, string) error
-	Free()
+// Options configures how a GitClient authenticates and verifies remotes.
+type Options struct {
+	Auth            AuthMethod
+	HostKeyVerifier HostKeyVerifier
+	// Backend selects the underlying git implementation for CheckoutFromURL. The zero value is
+	// BackendLibgit2. OpenRepositoryWithOptions does not read it and always uses BackendLibgit2.
+	Backend Backend
 }
 
-// Gitter is an interface representing the required operations for this library that a repository must implement
-type Gitter interface {
-	Free()
-	RemotesLookup(string) (Fetcher, error)
-	GetTag(string) (*git.Tag, error)
-	CheckoutTree(*git.Tag, string, *git.CheckoutOpts) error
+func openRepositoryWithBackend(path string, backend Backend, opts Options) (gc *GitClient, err error) {
+	repository, err := openGitter(path, backend)
+	if err != nil {
+		return nil, err
+	}
+	if opts.HostKeyVerifier == nil {
+		opts.HostKeyVerifier = DefaultHostKeyVerifier()
+	}
+	return &GitClient{
+		repository:      repository,
+		repoPath:        path,
+		auth:            opts.Auth,
+		hostKeyVerifier: opts.HostKeyVerifier,
+	}, nil
 }
 
-// GitClient manages a reference to a git repository on disk
-type GitClient struct {
-	repository Gitter
-	repoPath   string
-	sshPath    string
+// OpenRepositoryWithOptions opens a reference to a git repository at the given path using the
+// libgit2 backend, with the supplied AuthMethod and HostKeyVerifier for remote operations. If
+// opts.HostKeyVerifier is nil, DefaultHostKeyVerifier is used.
+func OpenRepositoryWithOptions(path string, opts Options) (*GitClient, error) {
+	return openRepositoryWithBackend(path, BackendLibgit2, opts)
 }
 
-type gitterImpl struct {
-	r *git.Repository
+// OpenRepositoryWithBackend opens a reference to a git repository at the given path using the
+// requested Backend, authenticating with an SSH key named id_rsa/id_rsa.pub under sshpath
+func OpenRepositoryWithBackend(path, sshpath string, backend Backend) (*GitClient, error) {
+	return openRepositoryWithBackend(path, backend, Options{
+		Auth: SSHKey{PrivateKeyPath: dirpath.Join(sshpath, "id_rsa")},
+	})
 }
 
-func (g *gitterImpl) Free() {
-	g.r.Free()
+// OpenRepository opens a reference to a git repository at the given path using the libgit2
+// backend, authenticating with an SSH key named id_rsa/id_rsa.pub under sshpath
+func OpenRepository(path, sshpath string) (*GitClient, error) {
+	return OpenRepositoryWithOptions(path, Options{
+		Auth: SSHKey{PrivateKeyPath: dirpath.Join(sshpath, "id_rsa")},
+	})
 }
 
-func (g *gitterImpl) RemotesLookup(n string) (Fetcher, error) {
-	return g.r.Remotes.Lookup(n)
+// Free ensures that resources held by the git client are properly freed
+func (gc *GitClient) Free() {
+	gc.repository.Free()
 }
 
-func (g *gitterImpl) GetTag(tag string) (*git.Tag, error) {
-	odb, err := g.r.Odb()
+func getFileInfoByName(prefix, name string) (os.FileInfo, error) {
+	finfo, err := ioutil.ReadDir(prefix)
 	if err != nil {
 		return nil, err
 	}
-	defer odb.Free()
-
-	var t *git.Tag
-	odb.ForEach(func(oid *git.Oid) error {
-		obj, err := g.r.Lookup(oid)
-		if err != nil {
-			return err
-		}
-		tObj, err := obj.AsTag()
-		if err == nil {
-			if tObj.Name() == tag {
-				t = tObj
-			}
+	for _, v := range finfo {
+		if v.Name() == name {
+			return v, nil
 		}
-		return nil
-	})
-	return t, err
+	}
+	return nil, errors.New("ERRNF")
 }
 
-func (g *gitterImpl) CheckoutTree(t *git.Tag, tag string, o *git.CheckoutOpts) error {
-	tagCommit, err := t.Target().AsCommit()
+// checkout runs the shared fetch, checkout-tree, SetHead, and sparse-prune pipeline for the given
+// strategy, returning the commit and ref it resolved and materialized onto disk.
+func (gc *GitClient) checkout(strat CheckoutStrategy) (oid Oid, ref string, err error) {
+	r, err := gc.repository.RemotesLookup("origin")
 	if err != nil {
-		return err
+		return "", "", err
 	}
-	defer tagCommit.Free()
+	defer r.Free()
 
-	tree, err := tagCommit.Tree()
+	err = r.Fetch(refspecsForStrategy(strat), gc.auth, gc.hostKeyVerifier)
 	if err != nil {
-		return err
+		return "", "", err
 	}
-	defer tree.Free()
 
-	err = g.r.CheckoutTree(tree, checkoutOpts)
+	oid, ref, err = strat.Resolve(gc.repository)
 	if err != nil {
-		return err
+		return "", "", err
 	}
 
-	err = g.r.SetHead("refs/tags/" + tag)
+	err = gc.repository.CheckoutTree(oid, ref)
 	if err != nil {
-		return err
+		return "", "", err
 	}
-	return nil
-}
 
-// OpenRepository opens a reference to a git repository at the given path
-func OpenRepository(path, sshpath string) (gc *GitClient, err error) {
-	repo, err := git.OpenRepository(path)
-	if err != nil {
-		return nil, err
+	if err = gc.pruneSparse(); err != nil {
+		return "", "", err
 	}
-	gc = &GitClient{}
-	gi := &gitterImpl{repo}
-	gc.repository = gi
-	gc.repoPath = path
-	gc.sshPath = sshpath
-	return
-}
 
-// Free ensures that resources held by the git client are properly freed
-func (gc *GitClient) Free() {
-	gc.repository.Free()
+	return oid, ref, nil
 }
 
-func getFetchOpts(gc *GitClient) *git.FetchOptions {
-	return &git.FetchOptions{
-		RemoteCallbacks: git.RemoteCallbacks{
-			CertificateCheckCallback: func(*git.Certificate, bool, string) git.ErrorCode {
-				return git.ErrOk
-			},
-			CredentialsCallback: func(string, string, git.CredType) (git.ErrorCode, *git.Cred) {
-				ret, cred := git.NewCredSshKey("git", dirpath.Join(gc.sshPath, "id_rsa.pub"), dirpath.Join(gc.sshPath, "id_rsa"), "")
-				return git.ErrorCode(ret), &cred
-			},
-		},
+// checkoutContext is the context-aware counterpart to checkout: it runs the same fetch,
+// checkout-tree, and sparse-prune pipeline, but honors ctx for cancellation during the fetch and
+// reports progress via progress, if non-nil.
+func (gc *GitClient) checkoutContext(ctx context.Context, strat CheckoutStrategy, progress func(ProgressEvent)) (oid Oid, ref string, err error) {
+	r, err := gc.repository.RemotesLookup("origin")
+	if err != nil {
+		return "", "", err
 	}
-}
+	defer r.Free()
 
-func getFileInfoByName(prefix, name string) (os.FileInfo, error) {
-	finfo, err := ioutil.ReadDir(prefix)
+	err = r.FetchContext(ctx, refspecsForStrategy(strat), gc.auth, gc.hostKeyVerifier, progress)
 	if err != nil {
-		return nil, err
+		return "", "", err
 	}
-	for _, v := range finfo {
-		if v.Name() == name {
-			return v, nil
-		}
+
+	oid, ref, err = strat.Resolve(gc.repository)
+	if err != nil {
+		return "", "", err
 	}
-	return nil, errors.New("ERRNF")
-}
 
-type sparseEntries []string
+	err = gc.repository.CheckoutTree(oid, ref)
+	if err != nil {
+		return "", "", err
+	}
 
-func (se sparseEntries) contains(i string) bool {
-	for _, v := range se {
-		if v == i || v == i+string(os.PathSeparator) {
-			return true
-		}
+	if err = gc.pruneSparse(); err != nil {
+		return "", "", err
 	}
-	return false
-}
 
-func isHidden(i string) bool {
-	return i[0] == '.'
+	return oid, ref, nil
 }
 
-// CheckoutTag instructs the git client to checkout the provided tag onto disk from the repository
-func (gc *GitClient) CheckoutTag(tag string) (err error) {
-	r, err := gc.repository.RemotesLookup("origin")
+// pruneSparse removes working tree entries not covered by .git/info/sparse-checkout, if present.
+func (gc *GitClient) pruneSparse() error {
+	gitDir, err := getFileInfoByName(gc.repoPath, ".git")
 	if err != nil {
 		return err
 	}
-	defer r.Free()
 
-	err = r.Fetch([]string{"+refs/heads/*:refs/remotes/origin/*", "refs/tags/*:refs/tags/*"}, getFetchOpts(gc), "")
+	infoPath := dirpath.Join(gc.repoPath, gitDir.Name())
+	info, err := getFileInfoByName(infoPath, "info")
 	if err != nil {
-		return err
+		if err.Error() != "ERRNF" {
+			return err
+		}
+		return nil
 	}
 
-	t, err := gc.repository.GetTag(tag)
-	if t != nil && err == nil {
-		defer t.Free()
-	} else {
-		return errors.New("Unabled to find specified tag")
+	infoPath = dirpath.Join(infoPath, info.Name())
+	sparse, err := getFileInfoByName(infoPath, "sparse-checkout")
+	if err != nil {
+		if err.Error() != "ERRNF" {
+			return err
+		}
+		return nil
 	}
 
-	err = gc.repository.CheckoutTree(t, tag, checkoutOpts)
+	sparseData, err := ioutil.ReadFile(dirpath.Join(infoPath, sparse.Name()))
 	if err != nil {
 		return err
 	}
 
-	workPath := gc.repoPath
-	g, err := getFileInfoByName(workPath, ".git")
+	patterns := newSparsePatternSet(strings.Split(string(sparseData), "\n"))
+	return pruneWorkingTree(gc.repoPath, gc.repoPath, patterns)
+}
+
+// pruneWorkingTree recursively walks dir (rooted at root), removing any file not included by
+// patterns. A directory that is not itself included is still walked, since it may hold included
+// paths (e.g. docs/ under /docs/api/), and is removed only if nothing is left in it afterwards.
+// The .git directory at the repo root is always preserved.
+func pruneWorkingTree(root, dir string, patterns sparsePatternSet) error {
+	entries, err := ioutil.ReadDir(dir)
 	if err != nil {
 		return err
 	}
 
-	sparseFlag := true
-	workPath = dirpath.Join(workPath, g.Name())
-	info, err := getFileInfoByName(workPath, "info")
-	if err != nil {
-		if err.Error() != "ERRNF" {
-			return err
+	for _, entry := range entries {
+		if dir == root && entry.Name() == ".git" {
+			continue
 		}
-		sparseFlag = false
-	}
 
-	if sparseFlag {
-		workPath = dirpath.Join(workPath, info.Name())
-
-		var sparse os.FileInfo
-		sparse, err = getFileInfoByName(workPath, "sparse-checkout")
+		fullPath := dirpath.Join(dir, entry.Name())
+		relPath, err := filepath.Rel(root, fullPath)
 		if err != nil {
-			if err.Error() != "ERRNF" {
-				return err
-			}
-			sparseFlag = false
+			return err
 		}
-		if sparse != nil {
-			workPath = dirpath.Join(workPath, sparse.Name())
-			sparseData, err := ioutil.ReadFile(workPath)
-			if err != nil {
+
+		included := patterns.Match(relPath, entry.IsDir())
+		if !included && !entry.IsDir() {
+			if err := os.RemoveAll(fullPath); err != nil {
 				return err
 			}
-			sparses := sparseEntries(strings.Split(string(sparseData), "\n"))
-			dirContents, err := ioutil.ReadDir(gc.repoPath)
-			if err != nil {
+			continue
+		}
+
+		if entry.IsDir() {
+			if err := pruneWorkingTree(root, fullPath, patterns); err != nil {
 				return err
 			}
-
-			for _, v := range dirContents {
-				if !sparses.contains(v.Name()) && !isHidden(v.Name()) {
-					err = os.RemoveAll(dirpath.Join(gc.repoPath, v.Name()))
-					if err != nil {
+			if !included {
+				remaining, err := ioutil.ReadDir(fullPath)
+				if err != nil {
+					return err
+				}
+				if len(remaining) == 0 {
+					if err := os.Remove(fullPath); err != nil {
 						return err
 					}
 				}
@@ -233,3 +229,52 @@ func (gc *GitClient) CheckoutTag(tag string) (err error) {
 	}
 	return nil
 }
+
+// CheckoutTag instructs the git client to checkout the provided tag onto disk from the repository
+func (gc *GitClient) CheckoutTag(tag string) (err error) {
+	_, _, err = gc.checkout(tagStrategy{tag: tag})
+	return err
+}
+
+// CheckoutBranch instructs the git client to checkout the tip of the provided branch onto disk
+// from the repository, returning the commit and ref that were checked out
+func (gc *GitClient) CheckoutBranch(name string) (oid Oid, ref string, err error) {
+	return gc.checkout(branchStrategy{branch: name})
+}
+
+// CheckoutCommit instructs the git client to checkout the provided commit hash onto disk from the
+// repository, returning the commit and ref that were checked out
+func (gc *GitClient) CheckoutCommit(hash string) (oid Oid, ref string, err error) {
+	return gc.checkout(commitStrategy{hash: hash})
+}
+
+// CheckoutSemVer resolves the highest tag satisfying constraint (e.g. ">=1.2.0 <2.0.0") and checks
+// it out, returning the commit and ref that were checked out
+func (gc *GitClient) CheckoutSemVer(constraint string) (oid Oid, ref string, err error) {
+	return gc.checkout(semVerStrategy{constraint: constraint})
+}
+
+// CheckoutTagContext behaves like CheckoutTag, but honors ctx for cancellation of the underlying
+// fetch and reports fetch progress via progress, if non-nil.
+func (gc *GitClient) CheckoutTagContext(ctx context.Context, tag string, progress func(ProgressEvent)) (err error) {
+	_, _, err = gc.checkoutContext(ctx, tagStrategy{tag: tag}, progress)
+	return err
+}
+
+// CheckoutBranchContext behaves like CheckoutBranch, but honors ctx for cancellation of the
+// underlying fetch and reports fetch progress via progress, if non-nil.
+func (gc *GitClient) CheckoutBranchContext(ctx context.Context, name string, progress func(ProgressEvent)) (oid Oid, ref string, err error) {
+	return gc.checkoutContext(ctx, branchStrategy{branch: name}, progress)
+}
+
+// CheckoutCommitContext behaves like CheckoutCommit, but honors ctx for cancellation of the
+// underlying fetch and reports fetch progress via progress, if non-nil.
+func (gc *GitClient) CheckoutCommitContext(ctx context.Context, hash string, progress func(ProgressEvent)) (oid Oid, ref string, err error) {
+	return gc.checkoutContext(ctx, commitStrategy{hash: hash}, progress)
+}
+
+// CheckoutSemVerContext behaves like CheckoutSemVer, but honors ctx for cancellation of the
+// underlying fetch and reports fetch progress via progress, if non-nil.
+func (gc *GitClient) CheckoutSemVerContext(ctx context.Context, constraint string, progress func(ProgressEvent)) (oid Oid, ref string, err error) {
+	return gc.checkoutContext(ctx, semVerStrategy{constraint: constraint}, progress)
+}
@@ -1,25 +1,30 @@
 package sciuromorpha
 
 import (
+	"context"
 	"os"
 	"path"
 	"strings"
 	"testing"
-
-	git "gopkg.in/libgit2/git2go.v24"
 )
 
-var se = sparseEntries([]string{"first", "second", "third"})
 var testHook string
 
 type testFetcher struct {
 	Fail bool
 }
 
-func (tf testFetcher) Fetch([]string, *git.FetchOptions, string) error {
+func (tf testFetcher) Fetch([]string, AuthMethod, HostKeyVerifier) error {
 	return nil
 }
 
+func (tf testFetcher) FetchContext(ctx context.Context, refspecs []string, auth AuthMethod, verifier HostKeyVerifier, progress func(ProgressEvent)) error {
+	if progress != nil {
+		progress(ProgressEvent{Phase: "transfer"})
+	}
+	return ctx.Err()
+}
+
 func (tf testFetcher) Free() {
 }
 
@@ -38,19 +43,36 @@ func (tg *testGitter) RemotesLookup(s string) (Fetcher, error) {
 	return testFetcher{}, nil
 }
 
-func (tg *testGitter) GetTag(s string) (*git.Tag, error) {
-	return &git.Tag{}, nil
+func (tg *testGitter) GetTag(s string) (*Tag, error) {
+	return &Tag{Name: s, Target: "deadbeef"}, nil
+}
+
+func (tg *testGitter) ListTags() ([]Tag, error) {
+	return nil, nil
 }
 
-func (tg *testGitter) CheckoutTree(*git.Tag, string, *git.CheckoutOpts) error {
+func (tg *testGitter) LookupBranch(s string) (Oid, error) {
+	return "deadbeef", nil
+}
+
+func (tg *testGitter) LookupCommit(hash string) (Oid, error) {
+	return Oid(hash), nil
+}
+
+func (tg *testGitter) CheckoutTree(Oid, string) error {
 	return nil
 }
 
+func (tg *testGitter) CommitInfo(Oid) (string, string, error) {
+	return "Test Author <test@example.com>", "test commit message", nil
+}
+
 var tg = testGitter{}
 var testClient = GitClient{
-	repository: &tg,
-	repoPath:   "",
-	sshPath:    "",
+	repository:      &tg,
+	repoPath:        "",
+	auth:            SSHKey{},
+	hostKeyVerifier: InsecureSkipHostKeyVerification{},
 }
 
 func createLocalDir(name string) (string, error) {
@@ -63,24 +85,6 @@ func createLocalDir(name string) (string, error) {
 	return result, err
 }
 
-func initRepo(path string) (*git.Repository, error) {
-	return git.InitRepository(path, false)
-}
-
-func openRepo(path, sshpath string) (*GitClient, error) {
-	p, err := createLocalDir(path)
-	if err != nil {
-		return nil, err
-	}
-	_, err = initRepo(p)
-	if err != nil {
-		return nil, err
-	}
-	return OpenRepository(path, sshpath, func(g *git.Repository) Gitter {
-		return &testGitter{}
-	})
-}
-
 func TestFree(t *testing.T) {
 	testClient.Free()
 	if testHook != "Free called" {
@@ -88,105 +92,70 @@ func TestFree(t *testing.T) {
 	}
 }
 
-func TestSparseEntriesDoesContain(t *testing.T) {
-	if !se.contains("second") {
+func TestSparsePatternSetTopLevelLiteral(t *testing.T) {
+	ps := newSparsePatternSet([]string{"first"})
+	if !ps.Match("first", false) {
 		t.Fail()
 	}
-}
-
-func TestSparseEntriesDoesNotContain(t *testing.T) {
-	if se.contains("fourth") {
+	if ps.Match("fourth", false) {
 		t.Fail()
 	}
 }
 
-func TestIsHidden(t *testing.T) {
-	if !isHidden(".hiddenDir") {
+func TestSparsePatternSetAnchoredDirectory(t *testing.T) {
+	ps := newSparsePatternSet([]string{"/docs/api/"})
+	if !ps.Match("docs/api", true) {
 		t.Fail()
 	}
-}
-
-func TestIsNotHidden(t *testing.T) {
-	if isHidden("nothiddendir") {
+	if !ps.Match("docs/api/readme.md", false) {
 		t.Fail()
 	}
-}
-
-func TestGetFetchOptsCredentialsCallbackEmptySSHPath(t *testing.T) {
-	opt := getFetchOpts(&testClient)
-	gitErr, cred := opt.RemoteCallbacks.CredentialsCallback("", "", git.CredTypeSshKey)
-	if gitErr != 0 {
-		t.Fail()
-	}
-	if cred == nil {
+	if ps.Match("docs/other", true) {
 		t.Fail()
 	}
 }
 
-func TestGetFetchOptsCredentialsCallbackNonexistentCredentials(t *testing.T) {
-	sshPath, err := createLocalDir(".ssh")
-	defer os.RemoveAll(sshPath)
-	if err != nil {
-		t.Error(err)
-	}
-
-	testClient.sshPath = sshPath
-	opt := getFetchOpts(&testClient)
-	gitErr, cred := opt.RemoteCallbacks.CredentialsCallback("", "", git.CredTypeSshKey)
-	// Strangely if the files don't exist this doesn't cause an error
-	if gitErr != 0 {
+func TestSparsePatternSetDoubleStarGlob(t *testing.T) {
+	ps := newSparsePatternSet([]string{"src/**/*.go"})
+	if !ps.Match("src/pkg/sub/file.go", false) {
 		t.Fail()
 	}
-	if cred == nil {
+	if ps.Match("src/pkg/sub/file.txt", false) {
 		t.Fail()
 	}
 }
 
-func TestGetFetchOptsCredentialsCallback(t *testing.T) {
-	// Create files for testing
-	sshPath, err := createLocalDir(".ssh")
-	defer os.RemoveAll(sshPath)
-	if err != nil {
-		t.Error(err)
-	}
-
-	_, err = os.OpenFile(path.Join(sshPath, "id_rsa.pub"), os.O_RDONLY|os.O_CREATE, 0666)
-	if err != nil {
-		t.Error(err)
-	}
-	_, err = os.OpenFile(path.Join(sshPath, "id_rsa"), os.O_RDONLY|os.O_CREATE, 0666)
-	if err != nil {
-		t.Error(err)
-	}
-	testClient.sshPath = sshPath
-	opt := getFetchOpts(&testClient)
-	gitErr, cred := opt.RemoteCallbacks.CredentialsCallback("", "", git.CredTypeSshKey)
-	if gitErr != 0 {
+func TestSparsePatternSetNegationExcludes(t *testing.T) {
+	ps := newSparsePatternSet([]string{"/docs/", "!/docs/api/"})
+	if !ps.Match("docs/guide.md", false) {
 		t.Fail()
 	}
-	if cred == nil {
+	if ps.Match("docs/api/readme.md", false) {
 		t.Fail()
 	}
 }
 
-func TestGetFetchOptsCertificateCheckCallback(t *testing.T) {
-	opt := getFetchOpts(&testClient)
-	gitErr := opt.RemoteCallbacks.CertificateCheckCallback(&git.Certificate{}, true, "")
-	if gitErr != git.ErrOk {
+func TestSparsePatternSetSkipsBlankAndCommentLines(t *testing.T) {
+	ps := newSparsePatternSet([]string{"", "# a comment", "first"})
+	if len(ps) != 1 {
 		t.Fail()
 	}
 }
 
-func TestCheckoutTagNoSparse(t *testing.T) {
-	testClient, err := openRepo("testing", "")
-	defer os.RemoveAll("testing")
+func TestDefaultHostKeyVerifierRejectsUnknownHost(t *testing.T) {
+	sshPath, err := createLocalDir(".ssh2")
+	defer os.RemoveAll(sshPath)
 	if err != nil {
 		t.Error(err)
 	}
 
-	err = testClient.CheckoutTag("test")
-	if err != nil {
-		t.Log(err)
+	knownHosts := path.Join(sshPath, "known_hosts")
+	if err := os.WriteFile(knownHosts, []byte{}, 0600); err != nil {
+		t.Error(err)
+	}
+
+	v := knownHostsVerifier{path: knownHosts}
+	if err := v.Verify("example.com", []byte("not-a-real-key")); err == nil {
 		t.Fail()
 	}
 }
@@ -225,6 +194,58 @@ func TestCheckoutTagNoRepoPathSet(t *testing.T) {
 	}
 }
 
+func TestCheckoutTagContextReportsProgress(t *testing.T) {
+	testDir, err := createLocalDir("contextprogress")
+	defer os.RemoveAll(testDir)
+	if err != nil {
+		t.Error(err)
+	}
+	if err := os.Mkdir(path.Join(testDir, ".git"), os.ModeDir|os.ModePerm); err != nil {
+		t.Error(err)
+	}
+	testClient.repoPath = testDir
+
+	var events []ProgressEvent
+	err = testClient.CheckoutTagContext(context.Background(), "test", func(e ProgressEvent) {
+		events = append(events, e)
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	if len(events) != 1 || events[0].Phase != "transfer" {
+		t.Fail()
+	}
+}
+
+func TestCheckoutTagContextCanceled(t *testing.T) {
+	testDir, err := createLocalDir("contextcanceled")
+	defer os.RemoveAll(testDir)
+	if err != nil {
+		t.Error(err)
+	}
+	testClient.repoPath = testDir
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = testClient.CheckoutTagContext(ctx, "test", nil)
+	if err != context.Canceled {
+		t.Fail()
+	}
+}
+
+func TestRefspecsForStrategyNarrowsTagAndBranch(t *testing.T) {
+	if got := refspecsForStrategy(tagStrategy{tag: "v1"}); len(got) != 1 || got[0] != "refs/tags/v1:refs/tags/v1" {
+		t.Fail()
+	}
+	if got := refspecsForStrategy(branchStrategy{branch: "main"}); len(got) != 1 || got[0] != "+refs/heads/main:refs/remotes/origin/main" {
+		t.Fail()
+	}
+	if got := refspecsForStrategy(commitStrategy{hash: "deadbeef"}); len(got) != len(defaultRefspecs()) {
+		t.Fail()
+	}
+}
+
 func TestCheckoutTagRepoPathNotExist(t *testing.T) {
 	testClient.repoPath = "/directorycertainlydoesntexist"
 	err := testClient.CheckoutTag("test")
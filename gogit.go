@@ -0,0 +1,233 @@
+package sciuromorpha
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"golang.org/x/crypto/ssh"
+)
+
+type goGitGitter struct {
+	r *git.Repository
+}
+
+func openGoGitGitter(path string) (Gitter, error) {
+	r, err := git.PlainOpen(path)
+	if err != nil {
+		return nil, err
+	}
+	return &goGitGitter{r: r}, nil
+}
+
+func cloneGoGitGitter(url, destPath string, depth int, auth AuthMethod, verifier HostKeyVerifier) (Gitter, error) {
+	transportAuth, err := goGitAuth(auth, verifier)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := git.PlainClone(destPath, false, &git.CloneOptions{
+		URL:   url,
+		Auth:  transportAuth,
+		Depth: depth,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &goGitGitter{r: r}, nil
+}
+
+func (g *goGitGitter) Free() {}
+
+func (g *goGitGitter) RemotesLookup(name string) (Fetcher, error) {
+	remote, err := g.r.Remote(name)
+	if err != nil {
+		return nil, err
+	}
+	return &goGitFetcher{remote: remote}, nil
+}
+
+func (g *goGitGitter) GetTag(name string) (*Tag, error) {
+	tags, err := g.ListTags()
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tags {
+		if t.Name == name {
+			return &t, nil
+		}
+	}
+	return nil, nil
+}
+
+// ListTags walks tag references via the repository's storer, resolving annotated tags to the
+// commit they point at.
+func (g *goGitGitter) ListTags() ([]Tag, error) {
+	iter, err := g.r.Tags()
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	var tags []Tag
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		target := ref.Hash()
+		if tagObj, err := g.r.TagObject(ref.Hash()); err == nil {
+			target = tagObj.Target
+		}
+		tags = append(tags, Tag{Name: ref.Name().Short(), Target: Oid(target.String())})
+		return nil
+	})
+	return tags, err
+}
+
+// LookupBranch resolves name against the local branch refs/heads/name. If no local branch exists
+// yet - e.g. name was only just fetched into refs/remotes/origin/name and never checked out -
+// it falls back to the origin remote-tracking branch and creates/updates refs/heads/name to match,
+// mirroring `git checkout <name>` against a freshly fetched remote branch.
+func (g *goGitGitter) LookupBranch(name string) (Oid, error) {
+	ref, err := g.r.Reference(plumbing.NewBranchReferenceName(name), true)
+	if err == nil {
+		return Oid(ref.Hash().String()), nil
+	}
+
+	remoteRef, rerr := g.r.Reference(plumbing.NewRemoteReferenceName("origin", name), true)
+	if rerr != nil {
+		return "", err
+	}
+
+	localRef := plumbing.NewHashReference(plumbing.NewBranchReferenceName(name), remoteRef.Hash())
+	if err := g.r.Storer.SetReference(localRef); err != nil {
+		return "", err
+	}
+
+	return Oid(remoteRef.Hash().String()), nil
+}
+
+func (g *goGitGitter) LookupCommit(hash string) (Oid, error) {
+	c, err := g.r.CommitObject(plumbing.NewHash(hash))
+	if err != nil {
+		return "", err
+	}
+	return Oid(c.Hash.String()), nil
+}
+
+func (g *goGitGitter) CommitInfo(oid Oid) (author, message string, err error) {
+	c, err := g.r.CommitObject(plumbing.NewHash(oid.String()))
+	if err != nil {
+		return "", "", err
+	}
+	return fmt.Sprintf("%s <%s>", c.Author.Name, c.Author.Email), c.Message, nil
+}
+
+// CheckoutTree checks out oid with Force: true, matching the libgit2 backend's CheckoutUseTheirs
+// behavior. ref is a canonical reference name (refs/heads/..., refs/tags/...) for branch/tag/
+// semver strategies, so HEAD is left on that branch/tag like the libgit2 backend's SetHead(ref).
+// commitStrategy has no ref to point at and passes the bare hex hash instead, which is checked
+// out by Hash alone, leaving HEAD detached like libgit2's SetHeadDetached.
+func (g *goGitGitter) CheckoutTree(oid Oid, ref string) error {
+	wt, err := g.r.Worktree()
+	if err != nil {
+		return err
+	}
+
+	opts := &git.CheckoutOptions{Force: true}
+	if strings.HasPrefix(ref, "refs/") {
+		opts.Branch = plumbing.ReferenceName(ref)
+	} else {
+		opts.Hash = plumbing.NewHash(oid.String())
+	}
+	return wt.Checkout(opts)
+}
+
+type goGitFetcher struct {
+	remote *git.Remote
+}
+
+func (f *goGitFetcher) Free() {}
+
+func (f *goGitFetcher) Fetch(refspecs []string, auth AuthMethod, verifier HostKeyVerifier) error {
+	return f.FetchContext(context.Background(), refspecs, auth, verifier, nil)
+}
+
+// FetchContext delegates to go-git's own context support, which cancels the fetch directly rather
+// than requiring a goroutine. If progress is non-nil, it receives one "sideband" ProgressEvent per
+// write of sideband output, with Bytes set to the length of that write; the text itself is not
+// passed on, and go-git doesn't expose the structured object counts libgit2 does.
+func (f *goGitFetcher) FetchContext(ctx context.Context, refspecs []string, auth AuthMethod, verifier HostKeyVerifier, progress func(ProgressEvent)) error {
+	specs := make([]config.RefSpec, len(refspecs))
+	for i, s := range refspecs {
+		specs[i] = config.RefSpec(s)
+	}
+
+	transportAuth, err := goGitAuth(auth, verifier)
+	if err != nil {
+		return err
+	}
+
+	opts := &git.FetchOptions{
+		RefSpecs: specs,
+		Auth:     transportAuth,
+	}
+	if progress != nil {
+		opts.Progress = progressWriter{fn: progress}
+	}
+
+	err = f.remote.FetchContext(ctx, opts)
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return err
+	}
+	return nil
+}
+
+// progressWriter adapts go-git's io.Writer-based fetch progress reporting to ProgressEvent.
+type progressWriter struct {
+	fn func(ProgressEvent)
+}
+
+func (w progressWriter) Write(p []byte) (int, error) {
+	w.fn(ProgressEvent{Phase: "sideband", Bytes: len(p)})
+	return len(p), nil
+}
+
+func goGitAuth(auth AuthMethod, verifier HostKeyVerifier) (transport.AuthMethod, error) {
+	switch a := auth.(type) {
+	case SSHKey:
+		method, err := gitssh.NewPublicKeysFromFile("git", a.PrivateKeyPath, a.Passphrase)
+		if err != nil {
+			return nil, err
+		}
+		method.HostKeyCallback = goGitHostKeyCallback(verifier)
+		return method, nil
+	case SSHAgent:
+		method, err := gitssh.NewSSHAgentAuth("git")
+		if err != nil {
+			return nil, err
+		}
+		method.HostKeyCallback = goGitHostKeyCallback(verifier)
+		return method, nil
+	case HTTPBasic:
+		return &githttp.BasicAuth{Username: a.User, Password: a.Password}, nil
+	case HTTPToken:
+		return &githttp.TokenAuth{Token: a.Token}, nil
+	default:
+		return nil, nil
+	}
+}
+
+func goGitHostKeyCallback(verifier HostKeyVerifier) ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		if verifier == nil {
+			return nil
+		}
+		return verifier.Verify(hostname, key.Marshal())
+	}
+}
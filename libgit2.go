@@ -0,0 +1,305 @@
+//go:build !nolibgit2
+// +build !nolibgit2
+
+package sciuromorpha
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	git "gopkg.in/libgit2/git2go.v24"
+)
+
+var libgit2CheckoutOpts = &git.CheckoutOpts{
+	Strategy: git.CheckoutSafe | git.CheckoutRecreateMissing | git.CheckoutAllowConflicts | git.CheckoutUseTheirs,
+}
+
+type libgit2Gitter struct {
+	r *git.Repository
+}
+
+func openLibgit2Gitter(path string) (Gitter, error) {
+	repo, err := git.OpenRepository(path)
+	if err != nil {
+		return nil, err
+	}
+	return &libgit2Gitter{r: repo}, nil
+}
+
+// cloneLibgit2Gitter clones url into destPath. git2go.v24 predates libgit2's shallow-clone
+// support, so there is no depth parameter here - see cloneGoGitGitter for shallow clones.
+func cloneLibgit2Gitter(url, destPath string, auth AuthMethod, verifier HostKeyVerifier) (Gitter, error) {
+	repo, err := git.Clone(url, destPath, &git.CloneOptions{
+		FetchOptions: libgit2FetchOptions(nil, auth, verifier, nil),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &libgit2Gitter{r: repo}, nil
+}
+
+func (g *libgit2Gitter) Free() {
+	g.r.Free()
+}
+
+func (g *libgit2Gitter) RemotesLookup(n string) (Fetcher, error) {
+	remote, err := g.r.Remotes.Lookup(n)
+	if err != nil {
+		return nil, err
+	}
+	return &libgit2Fetcher{remote: remote}, nil
+}
+
+func (g *libgit2Gitter) GetTag(name string) (*Tag, error) {
+	tags, err := g.ListTags()
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tags {
+		if t.Name == name {
+			return &t, nil
+		}
+	}
+	return nil, nil
+}
+
+func (g *libgit2Gitter) ListTags() ([]Tag, error) {
+	odb, err := g.r.Odb()
+	if err != nil {
+		return nil, err
+	}
+	defer odb.Free()
+
+	var tags []Tag
+	err = odb.ForEach(func(oid *git.Oid) error {
+		obj, err := g.r.Lookup(oid)
+		if err != nil {
+			return err
+		}
+		tObj, err := obj.AsTag()
+		if err != nil {
+			return nil
+		}
+		defer tObj.Free()
+
+		commit, err := tObj.Target().AsCommit()
+		if err != nil {
+			return nil
+		}
+		defer commit.Free()
+
+		tags = append(tags, Tag{Name: tObj.Name(), Target: Oid(commit.Id().String())})
+		return nil
+	})
+	return tags, err
+}
+
+// LookupBranch resolves name against the local branch refs/heads/name. If no local branch exists
+// yet - e.g. name was only just fetched into refs/remotes/origin/name and never checked out -
+// it falls back to the origin remote-tracking branch and creates/updates refs/heads/name to match,
+// mirroring `git checkout <name>` against a freshly fetched remote branch.
+func (g *libgit2Gitter) LookupBranch(name string) (Oid, error) {
+	b, err := g.r.LookupBranch(name, git.BranchLocal)
+	if err == nil {
+		defer b.Free()
+		return Oid(b.Target().String()), nil
+	}
+
+	rb, rerr := g.r.LookupBranch("origin/"+name, git.BranchRemote)
+	if rerr != nil {
+		return "", err
+	}
+	defer rb.Free()
+
+	target := rb.Target()
+	ref, err := g.r.References.Create("refs/heads/"+name, target, true, "")
+	if err != nil {
+		return "", err
+	}
+	defer ref.Free()
+
+	return Oid(target.String()), nil
+}
+
+func (g *libgit2Gitter) LookupCommit(hash string) (Oid, error) {
+	oid, err := git.NewOid(hash)
+	if err != nil {
+		return "", err
+	}
+
+	c, err := g.r.LookupCommit(oid)
+	if err != nil {
+		return "", err
+	}
+	defer c.Free()
+
+	return Oid(c.Id().String()), nil
+}
+
+func (g *libgit2Gitter) CommitInfo(oid Oid) (author, message string, err error) {
+	gitOid, err := git.NewOid(oid.String())
+	if err != nil {
+		return "", "", err
+	}
+
+	c, err := g.r.LookupCommit(gitOid)
+	if err != nil {
+		return "", "", err
+	}
+	defer c.Free()
+
+	a := c.Author()
+	return fmt.Sprintf("%s <%s>", a.Name, a.Email), c.Message(), nil
+}
+
+// CheckoutTree checks out oid's tree, then points HEAD at ref. ref is a canonical reference name
+// (refs/heads/..., refs/tags/...) for branch/tag/semver strategies, but commitStrategy has no ref
+// to point at and passes the bare hex hash instead - SetHead rejects that, so it's detected here
+// and checked out via SetHeadDetached instead, leaving HEAD detached at oid like a plain
+// `git checkout <hash>`.
+func (g *libgit2Gitter) CheckoutTree(oid Oid, ref string) error {
+	gitOid, err := git.NewOid(oid.String())
+	if err != nil {
+		return err
+	}
+
+	commit, err := g.r.LookupCommit(gitOid)
+	if err != nil {
+		return err
+	}
+	defer commit.Free()
+
+	tree, err := commit.Tree()
+	if err != nil {
+		return err
+	}
+	defer tree.Free()
+
+	if err := g.r.CheckoutTree(tree, libgit2CheckoutOpts); err != nil {
+		return err
+	}
+
+	if !strings.HasPrefix(ref, "refs/") {
+		return g.r.SetHeadDetached(gitOid)
+	}
+	return g.r.SetHead(ref)
+}
+
+type libgit2Fetcher struct {
+	remote *git.Remote
+}
+
+func (f *libgit2Fetcher) Free() {
+	f.remote.Free()
+}
+
+func (f *libgit2Fetcher) Fetch(refspecs []string, auth AuthMethod, verifier HostKeyVerifier) error {
+	return f.remote.Fetch(refspecs, libgit2FetchOptions(nil, auth, verifier, nil), "")
+}
+
+// FetchContext runs the fetch on a goroutine so it can honor ctx. Canceling ctx does not free the
+// remote out from under the in-flight git_remote_fetch call - that call and its progress
+// callbacks all run on the goroutine, so freeing here would race a concurrent use of the same C
+// object. Instead the transfer/sideband callbacks installed by libgit2FetchOptions poll ctx and
+// return git.ErrUser to make libgit2 abort the operation on its own thread; we then wait for the
+// goroutine to actually return before handing ctx.Err() back to the caller.
+func (f *libgit2Fetcher) FetchContext(ctx context.Context, refspecs []string, auth AuthMethod, verifier HostKeyVerifier, progress func(ProgressEvent)) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- f.remote.Fetch(refspecs, libgit2FetchOptions(ctx, auth, verifier, progress), "")
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		<-done
+		return ctx.Err()
+	}
+}
+
+// libgit2FetchOptions builds the RemoteCallbacks used for a fetch. When ctx is non-nil, the
+// transfer/sideband callbacks check ctx.Done() on every invocation and return git.ErrUser to
+// abort the fetch from inside libgit2 rather than freeing the remote while it's in use.
+func libgit2FetchOptions(ctx context.Context, auth AuthMethod, verifier HostKeyVerifier, progress func(ProgressEvent)) *git.FetchOptions {
+	callbacks := git.RemoteCallbacks{
+		CertificateCheckCallback: func(cert *git.Certificate, valid bool, hostname string) git.ErrorCode {
+			if verifier == nil {
+				return git.ErrOk
+			}
+			if cert.Kind != git.CertificateHostkey {
+				if valid {
+					return git.ErrOk
+				}
+				return git.ErrCertificate
+			}
+			if err := verifier.Verify(hostname, cert.Hostkey.Hostkey); err != nil {
+				return git.ErrCertificate
+			}
+			return git.ErrOk
+		},
+		CredentialsCallback: func(url, username string, allowed git.CredType) (git.ErrorCode, *git.Cred) {
+			return libgit2Cred(auth, username)
+		},
+	}
+
+	if progress != nil || ctx != nil {
+		callbacks.TransferProgressCallback = func(stats git.TransferProgress) git.ErrorCode {
+			if ctx != nil {
+				select {
+				case <-ctx.Done():
+					return git.ErrUser
+				default:
+				}
+			}
+			if progress != nil {
+				progress(ProgressEvent{
+					Phase:    "transfer",
+					Received: int(stats.ReceivedObjects),
+					Total:    int(stats.TotalObjects),
+					Bytes:    int(stats.ReceivedBytes),
+				})
+			}
+			return git.ErrOk
+		}
+		callbacks.SidebandProgressCallback = func(str string) git.ErrorCode {
+			if ctx != nil {
+				select {
+				case <-ctx.Done():
+					return git.ErrUser
+				default:
+				}
+			}
+			if progress != nil {
+				progress(ProgressEvent{Phase: "sideband", Bytes: len(str)})
+			}
+			return git.ErrOk
+		}
+	}
+
+	return &git.FetchOptions{RemoteCallbacks: callbacks}
+}
+
+func libgit2Cred(auth AuthMethod, username string) (git.ErrorCode, *git.Cred) {
+	if username == "" {
+		username = "git"
+	}
+	switch a := auth.(type) {
+	case SSHKey:
+		ret, cred := git.NewCredSshKey(username, a.PrivateKeyPath+".pub", a.PrivateKeyPath, a.Passphrase)
+		return git.ErrorCode(ret), &cred
+	case SSHAgent:
+		ret, cred := git.NewCredSshKeyFromAgent(username)
+		return git.ErrorCode(ret), &cred
+	case HTTPBasic:
+		ret, cred := git.NewCredUserpassPlaintext(a.User, a.Password)
+		return git.ErrorCode(ret), &cred
+	case HTTPToken:
+		ret, cred := git.NewCredUserpassPlaintext(a.Token, "")
+		return git.ErrorCode(ret), &cred
+	default:
+		ret, cred := git.NewCredSshKey(username, "", "", "")
+		return git.ErrorCode(ret), &cred
+	}
+}
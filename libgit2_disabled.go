@@ -0,0 +1,14 @@
+//go:build nolibgit2
+// +build nolibgit2
+
+package sciuromorpha
+
+import "errors"
+
+func openLibgit2Gitter(path string) (Gitter, error) {
+	return nil, errors.New("libgit2 backend not available: built with the nolibgit2 build tag")
+}
+
+func cloneLibgit2Gitter(url, destPath string, auth AuthMethod, verifier HostKeyVerifier) (Gitter, error) {
+	return nil, errors.New("libgit2 backend not available: built with the nolibgit2 build tag")
+}
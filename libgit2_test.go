@@ -0,0 +1,217 @@
+//go:build !nolibgit2
+// +build !nolibgit2
+
+package sciuromorpha
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+
+	git "gopkg.in/libgit2/git2go.v24"
+)
+
+func initRepo(path string) (*git.Repository, error) {
+	return git.InitRepository(path, false)
+}
+
+func openRepo(dir, sshpath string) (*GitClient, error) {
+	p, err := createLocalDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	_, err = initRepo(p)
+	if err != nil {
+		return nil, err
+	}
+	return OpenRepository(dir, sshpath)
+}
+
+// commitToNewBranch creates an empty commit in repo and points a new branch named branch at it.
+func commitToNewBranch(repo *git.Repository, branch string) error {
+	sig := &git.Signature{Name: "Test", Email: "test@example.com"}
+
+	bld, err := repo.TreeBuilder()
+	if err != nil {
+		return err
+	}
+	defer bld.Free()
+
+	treeID, err := bld.Write()
+	if err != nil {
+		return err
+	}
+	tree, err := repo.LookupTree(treeID)
+	if err != nil {
+		return err
+	}
+	defer tree.Free()
+
+	commitID, err := repo.CreateCommit("", sig, sig, "test commit", tree)
+	if err != nil {
+		return err
+	}
+	commit, err := repo.LookupCommit(commitID)
+	if err != nil {
+		return err
+	}
+	defer commit.Free()
+
+	b, err := repo.CreateBranch(branch, commit, false)
+	if err != nil {
+		return err
+	}
+	defer b.Free()
+	return nil
+}
+
+func TestLibgit2LookupBranchTracksFetchedRemoteBranch(t *testing.T) {
+	upstreamPath, err := createLocalDir("branchupstream")
+	defer os.RemoveAll(upstreamPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	upstream, err := initRepo(upstreamPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := commitToNewBranch(upstream, "feature-x"); err != nil {
+		t.Fatal(err)
+	}
+
+	localPath, err := createLocalDir("branchlocal")
+	defer os.RemoveAll(localPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	local, err := initRepo(localPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	remote, err := local.Remotes.Create("origin", upstreamPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer remote.Free()
+	if err := remote.Fetch([]string{"+refs/heads/feature-x:refs/remotes/origin/feature-x"}, nil, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// feature-x has never been checked out locally, so only refs/remotes/origin/feature-x exists.
+	g := &libgit2Gitter{r: local}
+	oid, err := g.LookupBranch("feature-x")
+	if err != nil {
+		t.Fatalf("expected LookupBranch to fall back to the remote-tracking branch, got %v", err)
+	}
+	if oid == "" {
+		t.Fatal("expected a resolved commit oid")
+	}
+
+	if _, _, err := (branchStrategy{branch: "feature-x"}).Resolve(g); err != nil {
+		t.Fatalf("branchStrategy.Resolve failed against a fetched-but-not-local branch: %v", err)
+	}
+}
+
+func TestCheckoutTagNoOriginRemote(t *testing.T) {
+	testClient, err := openRepo("testing", "")
+	defer os.RemoveAll("testing")
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = testClient.CheckoutTag("test")
+	if err == nil {
+		t.Fail()
+	}
+}
+
+func TestLibgit2CredEmptyKeyPath(t *testing.T) {
+	gitErr, cred := libgit2Cred(SSHKey{}, "")
+	if gitErr != 0 {
+		t.Fail()
+	}
+	if cred == nil {
+		t.Fail()
+	}
+}
+
+func TestLibgit2CredNonexistentCredentials(t *testing.T) {
+	sshPath, err := createLocalDir(".ssh")
+	defer os.RemoveAll(sshPath)
+	if err != nil {
+		t.Error(err)
+	}
+
+	gitErr, cred := libgit2Cred(SSHKey{PrivateKeyPath: path.Join(sshPath, "id_rsa")}, "")
+	// Strangely if the files don't exist this doesn't cause an error
+	if gitErr != 0 {
+		t.Fail()
+	}
+	if cred == nil {
+		t.Fail()
+	}
+}
+
+func TestLibgit2CredSSHKey(t *testing.T) {
+	sshPath, err := createLocalDir(".ssh")
+	defer os.RemoveAll(sshPath)
+	if err != nil {
+		t.Error(err)
+	}
+
+	_, err = os.OpenFile(path.Join(sshPath, "id_rsa.pub"), os.O_RDONLY|os.O_CREATE, 0666)
+	if err != nil {
+		t.Error(err)
+	}
+	_, err = os.OpenFile(path.Join(sshPath, "id_rsa"), os.O_RDONLY|os.O_CREATE, 0666)
+	if err != nil {
+		t.Error(err)
+	}
+
+	gitErr, cred := libgit2Cred(SSHKey{PrivateKeyPath: path.Join(sshPath, "id_rsa")}, "")
+	if gitErr != 0 {
+		t.Fail()
+	}
+	if cred == nil {
+		t.Fail()
+	}
+}
+
+func TestLibgit2FetchOptionsCertificateCheckInsecureSkip(t *testing.T) {
+	opt := libgit2FetchOptions(nil, SSHKey{}, InsecureSkipHostKeyVerification{}, nil)
+	gitErr := opt.RemoteCallbacks.CertificateCheckCallback(&git.Certificate{}, true, "")
+	if gitErr != git.ErrOk {
+		t.Fail()
+	}
+}
+
+func TestLibgit2FetchOptionsProgressCallback(t *testing.T) {
+	var got ProgressEvent
+	opt := libgit2FetchOptions(nil, SSHKey{}, InsecureSkipHostKeyVerification{}, func(e ProgressEvent) {
+		got = e
+	})
+	if opt.RemoteCallbacks.TransferProgressCallback == nil {
+		t.Fatal("expected TransferProgressCallback to be set")
+	}
+
+	gitErr := opt.RemoteCallbacks.TransferProgressCallback(git.TransferProgress{ReceivedObjects: 3, TotalObjects: 10})
+	if gitErr != git.ErrOk {
+		t.Fail()
+	}
+	if got.Phase != "transfer" || got.Received != 3 || got.Total != 10 {
+		t.Fail()
+	}
+}
+
+func TestLibgit2FetchOptionsAbortsOnCanceledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	opt := libgit2FetchOptions(ctx, SSHKey{}, InsecureSkipHostKeyVerification{}, nil)
+	gitErr := opt.RemoteCallbacks.TransferProgressCallback(git.TransferProgress{})
+	if gitErr != git.ErrUser {
+		t.Fatalf("expected ErrUser once ctx is canceled, got %v", gitErr)
+	}
+}
@@ -0,0 +1,120 @@
+package sciuromorpha
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// sparsePattern is a single compiled line from .git/info/sparse-checkout, following the same
+// gitignore-style conventions as upstream git: a leading / anchors the pattern to the repo root,
+// a trailing / restricts it to directories, and a leading ! excludes a path an earlier pattern
+// included.
+type sparsePattern struct {
+	negate  bool
+	dirOnly bool
+	reSelf  *regexp.Regexp
+	reUnder *regexp.Regexp
+}
+
+// matches reports whether relPath (slash-separated, relative to the repo root) is named by this
+// pattern or contained within a directory it names.
+func (p sparsePattern) matches(relPath string, isDir bool) bool {
+	if p.reUnder.MatchString(relPath) {
+		return true
+	}
+	if p.dirOnly && !isDir {
+		return false
+	}
+	return p.reSelf.MatchString(relPath)
+}
+
+// sparsePatternSet is a parsed .git/info/sparse-checkout file. Patterns are evaluated in file
+// order with later patterns overriding earlier ones, so a later `!` negation can exclude a path
+// an earlier pattern included, matching upstream git's behavior.
+type sparsePatternSet []sparsePattern
+
+// newSparsePatternSet parses the lines of a sparse-checkout file, skipping blank lines and `#`
+// comments.
+func newSparsePatternSet(lines []string) sparsePatternSet {
+	var set sparsePatternSet
+	for _, line := range lines {
+		if p, ok := compileSparsePattern(line); ok {
+			set = append(set, p)
+		}
+	}
+	return set
+}
+
+func compileSparsePattern(line string) (sparsePattern, bool) {
+	trimmed := strings.TrimSpace(strings.TrimRight(line, "\r"))
+	if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+		return sparsePattern{}, false
+	}
+
+	var p sparsePattern
+	if strings.HasPrefix(trimmed, "!") {
+		p.negate = true
+		trimmed = trimmed[1:]
+	}
+
+	anchored := strings.HasPrefix(trimmed, "/")
+	if anchored {
+		trimmed = trimmed[1:]
+	}
+	if strings.HasSuffix(trimmed, "/") {
+		p.dirOnly = true
+		trimmed = strings.TrimSuffix(trimmed, "/")
+	}
+	if strings.Contains(trimmed, "/") {
+		anchored = true
+	}
+
+	body := globToRegexpBody(trimmed)
+	prefix := "^"
+	if !anchored {
+		prefix = "^(?:.*/)?"
+	}
+	p.reSelf = regexp.MustCompile(prefix + body + "$")
+	p.reUnder = regexp.MustCompile(prefix + body + "/.*$")
+	return p, true
+}
+
+// globToRegexpBody translates a single gitignore-style glob - `*`, `?`, and `**` for arbitrary
+// depth - into the body of an anchored regular expression.
+func globToRegexpBody(pattern string) string {
+	var b strings.Builder
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		switch c := runes[i]; c {
+		case '*':
+			if i+2 < len(runes) && runes[i+1] == '*' && runes[i+2] == '/' {
+				// `**/` also matches zero directories, so a/**/b matches a/b.
+				b.WriteString("(?:.*/)?")
+				i += 2
+			} else if i+1 < len(runes) && runes[i+1] == '*' {
+				b.WriteString(".*")
+				i++
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
+
+// Match reports whether relPath should be included in the sparse checkout. Patterns are
+// evaluated in order, so the last matching pattern wins.
+func (ps sparsePatternSet) Match(relPath string, isDir bool) (included bool) {
+	relPath = strings.TrimPrefix(filepath.ToSlash(relPath), "/")
+	for _, p := range ps {
+		if p.matches(relPath, isDir) {
+			included = !p.negate
+		}
+	}
+	return included
+}
@@ -0,0 +1,141 @@
+package sciuromorpha
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	dirpath "path"
+	"strconv"
+	"strings"
+)
+
+// Commit describes a resolved commit, independent of the underlying git implementation.
+type Commit struct {
+	Hash      Oid
+	Reference string
+	Author    string
+	Message   string
+}
+
+// refStrategy resolves ref as a tag, then a branch, then a commit hash, in that order.
+type refStrategy struct {
+	ref string
+}
+
+func (s refStrategy) Resolve(repo Gitter) (Oid, string, error) {
+	if s.ref == "" {
+		return "", "", errors.New("no ref to resolve")
+	}
+	if oid, ref, err := (tagStrategy{tag: s.ref}).Resolve(repo); err == nil {
+		return oid, ref, nil
+	}
+	if oid, ref, err := (branchStrategy{branch: s.ref}).Resolve(repo); err == nil {
+		return oid, ref, nil
+	}
+	return (commitStrategy{hash: s.ref}).Resolve(repo)
+}
+
+// CheckoutFromURL clones or updates the repository at rawURL into destPath and checks out the ref
+// named by its query parameters, following the go-getter/terraform URL convention:
+//
+//	?ref=<tag|branch|commit>   the ref to check out (required)
+//	?depth=<n>                 shallow clone depth; only takes effect when the go-git backend is
+//	                            selected, since git2go.v24's libgit2 predates shallow-clone support
+//	?backend=<libgit2|go-git>  the Gitter backend to use (default libgit2, or opts.Backend if set)
+//	?sshkey=<path>             SSH private key to authenticate with, if opts.Auth is unset
+//	?sparse=<path1,path2,...>  populates .git/info/sparse-checkout before checkout
+//
+// The returned Commit describes exactly what was checked out, so callers can log or record it.
+func CheckoutFromURL(rawURL, destPath string, opts Options) (*GitClient, Commit, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, Commit{}, err
+	}
+
+	query := u.Query()
+	ref := query.Get("ref")
+	sparse := query.Get("sparse")
+
+	depth := 0
+	if d := query.Get("depth"); d != "" {
+		depth, err = strconv.Atoi(d)
+		if err != nil {
+			return nil, Commit{}, err
+		}
+	}
+	if key := query.Get("sshkey"); key != "" && opts.Auth == nil {
+		opts.Auth = SSHKey{PrivateKeyPath: key}
+	}
+
+	backend := opts.Backend
+	if b := query.Get("backend"); b != "" {
+		switch b {
+		case "go-git":
+			backend = BackendGoGit
+		case "libgit2":
+			backend = BackendLibgit2
+		default:
+			return nil, Commit{}, fmt.Errorf("unknown backend %q", b)
+		}
+	}
+
+	u.RawQuery = ""
+	remoteURL := u.String()
+
+	gc, err := openOrCloneRepository(remoteURL, destPath, backend, depth, opts)
+	if err != nil {
+		return nil, Commit{}, err
+	}
+
+	if sparse != "" {
+		if err := writeSparseCheckout(destPath, strings.Split(sparse, ",")); err != nil {
+			return nil, Commit{}, err
+		}
+	}
+
+	oid, refName, err := gc.checkout(refStrategy{ref: ref})
+	if err != nil {
+		return nil, Commit{}, err
+	}
+
+	author, message, err := gc.repository.CommitInfo(oid)
+	if err != nil {
+		return nil, Commit{}, err
+	}
+
+	return gc, Commit{Hash: oid, Reference: refName, Author: author, Message: message}, nil
+}
+
+// openOrCloneRepository opens destPath if it already holds a repository, otherwise clones url
+// into it.
+func openOrCloneRepository(url, destPath string, backend Backend, depth int, opts Options) (*GitClient, error) {
+	if opts.HostKeyVerifier == nil {
+		opts.HostKeyVerifier = DefaultHostKeyVerifier()
+	}
+
+	repository, err := openGitter(destPath, backend)
+	if err != nil {
+		repository, err = cloneGitter(url, destPath, backend, depth, opts.Auth, opts.HostKeyVerifier)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &GitClient{
+		repository:      repository,
+		repoPath:        destPath,
+		auth:            opts.Auth,
+		hostKeyVerifier: opts.HostKeyVerifier,
+	}, nil
+}
+
+// writeSparseCheckout populates destPath's .git/info/sparse-checkout with patterns, one per line.
+func writeSparseCheckout(destPath string, patterns []string) error {
+	infoDir := dirpath.Join(destPath, ".git", "info")
+	if err := os.MkdirAll(infoDir, 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(dirpath.Join(infoDir, "sparse-checkout"), []byte(strings.Join(patterns, "\n")+"\n"), 0644)
+}
@@ -0,0 +1,63 @@
+package sciuromorpha
+
+import (
+	"os"
+	"path"
+	"testing"
+)
+
+func TestWriteSparseCheckout(t *testing.T) {
+	dir, err := createLocalDir("sparsewrite")
+	defer os.RemoveAll(dir)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if err := writeSparseCheckout(dir, []string{"/docs/", "src/**/*.go"}); err != nil {
+		t.Error(err)
+	}
+
+	data, err := os.ReadFile(path.Join(dir, ".git", "info", "sparse-checkout"))
+	if err != nil {
+		t.Error(err)
+	}
+	if string(data) != "/docs/\nsrc/**/*.go\n" {
+		t.Fail()
+	}
+}
+
+type refFallbackGitter struct {
+	testGitter
+}
+
+func (g *refFallbackGitter) GetTag(string) (*Tag, error) {
+	return nil, nil
+}
+
+func (g *refFallbackGitter) LookupBranch(string) (Oid, error) {
+	return "", os.ErrNotExist
+}
+
+func TestRefStrategyFallsBackToCommit(t *testing.T) {
+	repo := &refFallbackGitter{}
+	oid, ref, err := (refStrategy{ref: "abc123"}).Resolve(repo)
+	if err != nil {
+		t.Error(err)
+	}
+	if oid != "abc123" || ref != "abc123" {
+		t.Fail()
+	}
+}
+
+func TestRefStrategyEmptyRef(t *testing.T) {
+	if _, _, err := (refStrategy{}).Resolve(&tg); err == nil {
+		t.Fail()
+	}
+}
+
+func TestCheckoutFromURLUnknownBackend(t *testing.T) {
+	_, _, err := CheckoutFromURL("https://example.com/repo.git?ref=main&backend=jj", "unused", Options{})
+	if err == nil {
+		t.Fail()
+	}
+}